@@ -0,0 +1,59 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"errors"
+	"net/url"
+
+	"golang.org/x/crypto/acme"
+)
+
+const defaultACMECacheDir = "/var/lib/csaf_aggregator/acme"
+
+var errNoACMEDomains = errors.New("[acme]: no domains configured")
+
+// acmeConfig configures automatic certificate provisioning for the
+// "serve" subcommand via ACME (e.g. Let's Encrypt).
+type acmeConfig struct {
+	Email            string   `toml:"email"`
+	DirectoryURL     string   `toml:"directory_url"`
+	Domains          []string `toml:"domains"`
+	CacheDir         string   `toml:"cache_dir"`
+	HTTPChallengeDir string   `toml:"http_challenge_dir"`
+}
+
+func (ac *acmeConfig) setDefaults(domain string) {
+	if ac.DirectoryURL == "" {
+		ac.DirectoryURL = acme.LetsEncryptURL
+	}
+	if ac.CacheDir == "" {
+		ac.CacheDir = defaultACMECacheDir
+	}
+	if len(ac.Domains) == 0 && domain != "" {
+		ac.Domains = []string{bareHost(domain)}
+	}
+}
+
+// bareHost strips the scheme from a config.Domain-style URL, since
+// autocert.HostWhitelist matches against the bare SNI hostname, not a
+// "https://..." URL.
+func bareHost(domain string) string {
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return domain
+}
+
+func (ac *acmeConfig) check() error {
+	if len(ac.Domains) == 0 {
+		return errNoACMEDomains
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import "testing"
+
+func TestBareHost(t *testing.T) {
+	tests := []struct{ domain, want string }{
+		{"https://example.com", "example.com"},
+		{"https://example.com/csaf", "example.com"},
+		{"example.com", "example.com"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := bareHost(tt.domain); got != tt.want {
+			t.Errorf("bareHost(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestAcmeConfigSetDefaults(t *testing.T) {
+	ac := &acmeConfig{}
+	ac.setDefaults("https://example.com")
+
+	if ac.DirectoryURL == "" {
+		t.Error("setDefaults should fill in DirectoryURL")
+	}
+	if ac.CacheDir == "" {
+		t.Error("setDefaults should fill in CacheDir")
+	}
+	if want := []string{"example.com"}; len(ac.Domains) != 1 || ac.Domains[0] != want[0] {
+		t.Errorf("setDefaults should default Domains to the bare host, got %v", ac.Domains)
+	}
+}
+
+func TestAcmeConfigSetDefaultsExplicitDomains(t *testing.T) {
+	ac := &acmeConfig{Domains: []string{"other.example.com"}}
+	ac.setDefaults("https://example.com")
+
+	if want := []string{"other.example.com"}; len(ac.Domains) != 1 || ac.Domains[0] != want[0] {
+		t.Errorf("setDefaults should not override explicitly configured Domains, got %v", ac.Domains)
+	}
+}
+
+func TestAcmeConfigCheck(t *testing.T) {
+	if err := (&acmeConfig{}).check(); err != errNoACMEDomains {
+		t.Fatalf("check() with no domains = %v, want errNoACMEDomains", err)
+	}
+	if err := (&acmeConfig{Domains: []string{"example.com"}}).check(); err != nil {
+		t.Fatalf("check() with a domain configured: %v", err)
+	}
+}
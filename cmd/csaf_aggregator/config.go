@@ -12,6 +12,8 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
 	"runtime"
@@ -20,7 +22,6 @@ import (
 	"github.com/BurntSushi/toml"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/csaf-poc/csaf_distribution/csaf"
-	"golang.org/x/time/rate"
 )
 
 const (
@@ -32,27 +33,47 @@ const (
 )
 
 type provider struct {
-	Name     string   `toml:"name"`
-	Domain   string   `toml:"domain"`
-	Rate     *float64 `toml:"rate"`
-	Insecure *bool    `toml:"insecure"`
+	Name                string   `toml:"name"`
+	Domain              string   `toml:"domain"`
+	Rate                *float64 `toml:"rate"`
+	Burst               *float64 `toml:"burst"`
+	Budget              *int64   `toml:"budget"`
+	Insecure            *bool    `toml:"insecure"`
+	ClientCert          *string  `toml:"client_cert"`
+	ClientKey           *string  `toml:"client_key"`
+	ClientKeyPassphrase *string  `toml:"client_key_passphrase"`
+	RootCAs             []string `toml:"root_cas"`
+
+	tlsCertMu  sync.Mutex
+	tlsCert    *tls.Certificate
+	tlsCertErr error
 }
 
 type config struct {
-	Workers    int                 `toml:"workers"`
-	Folder     string              `toml:"folder"`
-	Web        string              `toml:"web"`
-	Domain     string              `toml:"domain"`
-	Rate       *float64            `toml:"rate"`
-	Insecure   *bool               `toml:"insecure"`
-	Aggregator csaf.AggregatorInfo `toml:"aggregator"`
-	Providers  []*provider         `toml:"providers"`
-	Key        string              `toml:"key"`
-	Passphrase *string             `toml:"passphrase"`
+	Workers             int                 `toml:"workers"`
+	Folder              string              `toml:"folder"`
+	Web                 string              `toml:"web"`
+	Domain              string              `toml:"domain"`
+	Rate                *float64            `toml:"rate"`
+	Burst               *float64            `toml:"burst"`
+	Budget              *int64              `toml:"budget"`
+	Insecure            *bool               `toml:"insecure"`
+	ClientCert          *string             `toml:"client_cert"`
+	ClientKey           *string             `toml:"client_key"`
+	ClientKeyPassphrase *string             `toml:"client_key_passphrase"`
+	Aggregator          csaf.AggregatorInfo `toml:"aggregator"`
+	Providers           []*provider         `toml:"providers"`
+	Key                 string              `toml:"key"`
+	Passphrase          *string             `toml:"passphrase"`
+	Vault               *vaultConfig        `toml:"vault"`
+	ACME                *acmeConfig         `toml:"acme"`
 
 	keyMu  sync.Mutex
 	key    *crypto.Key
 	keyErr error
+
+	limitersOnce sync.Once
+	hostLimiters *limiters
 }
 
 func (c *config) cryptoKey() (*crypto.Key, error) {
@@ -64,40 +85,110 @@ func (c *config) cryptoKey() (*crypto.Key, error) {
 	if c.key != nil || c.keyErr != nil {
 		return c.key, c.keyErr
 	}
-	var f *os.File
-	if f, c.keyErr = os.Open(c.Key); c.keyErr != nil {
-		return nil, c.keyErr
+	var r io.ReadCloser
+	if isVaultRef(c.Key) {
+		if c.Vault == nil {
+			c.keyErr = errors.New("key is a vault reference but no [vault] section is configured")
+			return nil, c.keyErr
+		}
+		if r, c.keyErr = c.Vault.open(c.Key); c.keyErr != nil {
+			return nil, c.keyErr
+		}
+	} else {
+		if r, c.keyErr = os.Open(c.Key); c.keyErr != nil {
+			return nil, c.keyErr
+		}
 	}
-	defer f.Close()
-	c.key, c.keyErr = crypto.NewKeyFromArmoredReader(f)
+	defer r.Close()
+	c.key, c.keyErr = crypto.NewKeyFromArmoredReader(r)
 	return c.key, c.keyErr
 }
 
-func (c *config) httpClient(p *provider) client {
-
-	client := http.Client{}
-	if p.Insecure != nil && *p.Insecure || c.Insecure != nil && *c.Insecure {
-		client.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+// resolveSecrets replaces an "enc:", "env:", "file:" or "vault:"
+// indirected Passphrase with its plain value. No other field is
+// resolved here; client_key_passphrase is resolved when the client
+// certificate is loaded, the vault token in ensureToken.
+func (c *config) resolveSecrets() error {
+	if c.Passphrase != nil {
+		s, err := resolveSecret(c.Vault, *c.Passphrase)
+		if err != nil {
+			return fmt.Errorf("resolving passphrase: %w", err)
 		}
+		c.Passphrase = &s
 	}
-	if p.Rate == nil && c.Rate == nil {
-		return &client
-	}
+	return nil
+}
 
-	var r float64
+// limiters returns the registry of per-host rate limiters shared by
+// every client this config hands out.
+func (c *config) limiters() *limiters {
+	c.limitersOnce.Do(func() { c.hostLimiters = &limiters{} })
+	return c.hostLimiters
+}
+
+// resolvedLimits returns the effective rate, burst and budget for p,
+// falling back to the top-level defaults in c.
+func (c *config) resolvedLimits(p *provider) (r, burst float64, budget int64) {
 	if c.Rate != nil {
 		r = *c.Rate
 	}
 	if p.Rate != nil {
 		r = *p.Rate
 	}
-	return &limitingClient{
-		client:  &client,
-		limiter: rate.NewLimiter(rate.Limit(r), 1),
+	burst = 1
+	if c.Burst != nil {
+		burst = *c.Burst
+	}
+	if p.Burst != nil {
+		burst = *p.Burst
 	}
+	if c.Budget != nil {
+		budget = *c.Budget
+	}
+	if p.Budget != nil {
+		budget = *p.Budget
+	}
+	return r, burst, budget
+}
+
+func (c *config) httpClient(p *provider) client {
+
+	client := http.Client{}
+	// check() validated the provider's TLS material at startup, but
+	// root_cas is re-read from disk here, so a transient failure is
+	// still possible. Don't silently fall back to an unpinned
+	// transport in that case - fail every request instead.
+	var transport http.RoundTripper
+	if tlsConfig, err := c.tlsConfig(p); err != nil {
+		log.Printf("provider %q: rebuilding TLS configuration: %v", p.Name, err)
+		transport = &failingRoundTripper{
+			err: fmt.Errorf("provider %q: TLS configuration unavailable: %w", p.Name, err),
+		}
+	} else {
+		transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	if p.Rate == nil && c.Rate == nil && p.Burst == nil && c.Burst == nil &&
+		p.Budget == nil && c.Budget == nil {
+		client.Transport = transport
+		return &client
+	}
+
+	r, burst, budget := c.resolvedLimits(p)
+	host, err := hostOf(p)
+	if err != nil {
+		// check() does not verify that the domain parses to a
+		// host (e.g. a domain without a scheme). Such a provider
+		// gets a limiter keyed by its name, shared with no other.
+		host = p.Name
+	}
+	hl := c.limiters().forHost(host, r, burst, budget)
+
+	// The wait happens inside meteringRoundTripper so it can be
+	// measured accurately; don't also wrap in limitingClient, that
+	// would make every request wait on hl.limiter twice.
+	client.Transport = &meteringRoundTripper{next: transport, hl: hl}
+	return &client
 }
 
 func (c *config) checkProviders() error {
@@ -114,6 +205,9 @@ func (c *config) checkProviders() error {
 			return fmt.Errorf("provider '%s' is configured more than once", p.Name)
 		}
 		already[p.Name] = true
+		if err := c.checkClientCert(p); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -142,6 +236,10 @@ func (c *config) setDefaults() {
 	if c.Workers > len(c.Providers) {
 		c.Workers = len(c.Providers)
 	}
+
+	if c.ACME != nil {
+		c.ACME.setDefaults(c.Domain)
+	}
 }
 
 func (c *config) check() error {
@@ -149,10 +247,20 @@ func (c *config) check() error {
 		return errors.New("no providers given in configuration")
 	}
 
+	if isVaultRef(c.Key) && c.Vault == nil {
+		return errors.New("key is a vault reference but no [vault] section is configured")
+	}
+
 	if err := c.Aggregator.Validate(); err != nil {
 		return err
 	}
 
+	if c.ACME != nil {
+		if err := c.ACME.check(); err != nil {
+			return err
+		}
+	}
+
 	return c.checkProviders()
 }
 
@@ -168,9 +276,19 @@ func loadConfig(path string) (*config, error) {
 
 	cfg.setDefaults()
 
+	if cfg.Vault != nil {
+		if err := cfg.Vault.ensureToken(); err != nil {
+			return nil, fmt.Errorf("connecting to vault: %w", err)
+		}
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
 	if err := cfg.check(); err != nil {
 		return nil, err
 	}
 
 	return &cfg, nil
-}
\ No newline at end of file
+}
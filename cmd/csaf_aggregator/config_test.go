@@ -0,0 +1,39 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHTTPClientInstallsLimiterForBudgetOnly(t *testing.T) {
+	budget := int64(5)
+	cfg := &config{
+		Providers: []*provider{{Name: "test", Domain: "https://example.com", Budget: &budget}},
+	}
+	p := cfg.Providers[0]
+
+	client := cfg.httpClient(p).(*http.Client)
+	if _, ok := client.Transport.(*meteringRoundTripper); !ok {
+		t.Fatalf("httpClient with only a budget configured should install a meteringRoundTripper, got %T", client.Transport)
+	}
+}
+
+func TestHTTPClientNoLimiterWhenUnconfigured(t *testing.T) {
+	cfg := &config{
+		Providers: []*provider{{Name: "test", Domain: "https://example.com"}},
+	}
+	p := cfg.Providers[0]
+
+	client := cfg.httpClient(p).(*http.Client)
+	if _, ok := client.Transport.(*meteringRoundTripper); ok {
+		t.Fatal("httpClient with no rate, burst or budget configured should not install a limiter")
+	}
+}
@@ -0,0 +1,197 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var dryRunRate = flag.Bool("dry-run-rate", false,
+	"log the projected wait distribution for the configured rate limits and exit")
+
+var (
+	limiterRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csaf_aggregator_limiter_requests_total",
+		Help: "Requests issued per rate-limited host.",
+	}, []string{"host"})
+
+	limiterThrottledTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csaf_aggregator_limiter_throttled_total",
+		Help: "Requests that had to wait for the rate limiter per host.",
+	}, []string{"host"})
+
+	limiterBudgetExhaustedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "csaf_aggregator_limiter_budget_exhausted_total",
+		Help: "Requests rejected because a host's request budget was exhausted.",
+	}, []string{"host"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		limiterRequestsTotal, limiterThrottledTotal, limiterBudgetExhaustedTotal)
+}
+
+// hostBudget caps the total number of requests a hostLimiter will
+// allow before every further request is rejected.
+type hostBudget struct {
+	mu        sync.Mutex
+	remaining int64
+}
+
+func (b *hostBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.remaining <= 0 {
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// hostLimiter is shared by every provider client that talks to the
+// same host, so N workers configured for the same provider - or
+// different providers mirroring the same upstream - can't burst N
+// times the configured rate against it.
+type hostLimiter struct {
+	host    string
+	limiter *rate.Limiter
+	budget  *hostBudget
+}
+
+// limiters is a registry of hostLimiters keyed by resolved hostname.
+type limiters struct {
+	mu     sync.Mutex
+	byHost map[string]*hostLimiter
+}
+
+// forHost returns the shared hostLimiter for host, creating it with
+// rate r, burst and an optional request budget on first use.
+func (l *limiters) forHost(host string, r, burst float64, budget int64) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.byHost == nil {
+		l.byHost = map[string]*hostLimiter{}
+	}
+	if hl, ok := l.byHost[host]; ok {
+		return hl
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	limit := rate.Limit(r)
+	if r <= 0 {
+		// No rate configured, only a budget and/or burst: don't throttle,
+		// just track and enforce those.
+		limit = rate.Inf
+	}
+	hl := &hostLimiter{
+		host:    host,
+		limiter: rate.NewLimiter(limit, int(burst)),
+	}
+	if budget > 0 {
+		hl.budget = &hostBudget{remaining: budget}
+	}
+	l.byHost[host] = hl
+	return hl
+}
+
+// hostOf extracts the hostname a provider's requests go to.
+func hostOf(p *provider) (string, error) {
+	u, err := url.Parse(p.Domain)
+	if err != nil {
+		return "", fmt.Errorf("provider %q: invalid domain: %w", p.Name, err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("provider %q: domain %q has no host", p.Name, p.Domain)
+	}
+	return u.Host, nil
+}
+
+// meteringRoundTripper wraps a transport to record Prometheus metrics
+// for every actual request made against a rate-limited host.
+type meteringRoundTripper struct {
+	next http.RoundTripper
+	hl   *hostLimiter
+}
+
+// RoundTrip is the single place that both waits on the shared
+// per-host limiter and records whether that wait actually happened,
+// so the "throttled" counter reflects this request, not the bucket's
+// state after the fact.
+func (m *meteringRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !m.hl.budget.take() {
+		limiterBudgetExhaustedTotal.WithLabelValues(m.hl.host).Inc()
+		return nil, fmt.Errorf("request budget exhausted for host %q", m.hl.host)
+	}
+
+	if delay := m.hl.limiter.Reserve().Delay(); delay > 0 {
+		limiterThrottledTotal.WithLabelValues(m.hl.host).Inc()
+		time.Sleep(delay)
+	}
+	limiterRequestsTotal.WithLabelValues(m.hl.host).Inc()
+
+	next := m.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
+
+// maybeDryRunRate handles --dry-run-rate: if set, it logs the
+// projected wait distribution for cfg and reports that the caller
+// should exit instead of issuing any requests.
+func maybeDryRunRate(cfg *config) (handled bool) {
+	if !*dryRunRate {
+		return false
+	}
+	logProjectedRateWaits(cfg)
+	return true
+}
+
+// logProjectedRateWaits logs, for every configured provider, the
+// steady-state wait a request would incur under its resolved rate
+// limit, without issuing any requests. Used by --dry-run-rate.
+func logProjectedRateWaits(c *config) {
+	seen := map[string]bool{}
+	for _, p := range c.Providers {
+		host, err := hostOf(p)
+		if err != nil {
+			log.Printf("dry-run-rate: %v", err)
+			continue
+		}
+		if seen[host] {
+			continue
+		}
+		seen[host] = true
+
+		r, burst, budget := c.resolvedLimits(p)
+		if r == 0 {
+			log.Printf("dry-run-rate: host %q: unlimited", host)
+			continue
+		}
+		wait := time.Duration(float64(time.Second) / r)
+		msg := fmt.Sprintf("dry-run-rate: host %q: ~%s between requests, burst %.0f", host, wait, burst)
+		if budget > 0 {
+			msg += fmt.Sprintf(", budget %d requests", budget)
+		}
+		log.Println(msg)
+	}
+}
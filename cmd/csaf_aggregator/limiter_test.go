@@ -0,0 +1,64 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import "testing"
+
+func TestLimitersForHostSharesLimiter(t *testing.T) {
+	var ls limiters
+
+	a := ls.forHost("example.com", 1, 1, 0)
+	b := ls.forHost("example.com", 1, 1, 0)
+	if a.limiter != b.limiter {
+		t.Fatal("expected the same *rate.Limiter to be returned for the same host")
+	}
+
+	c := ls.forHost("other.example.com", 1, 1, 0)
+	if a.limiter == c.limiter {
+		t.Fatal("expected a different *rate.Limiter for a different host")
+	}
+}
+
+func TestHostBudgetTake(t *testing.T) {
+	b := &hostBudget{remaining: 2}
+	if !b.take() {
+		t.Fatal("first take should succeed")
+	}
+	if !b.take() {
+		t.Fatal("second take should succeed")
+	}
+	if b.take() {
+		t.Fatal("third take should fail, budget exhausted")
+	}
+}
+
+func TestHostBudgetNilAlwaysAllows(t *testing.T) {
+	var b *hostBudget
+	for i := 0; i < 3; i++ {
+		if !b.take() {
+			t.Fatal("a nil budget should never reject a request")
+		}
+	}
+}
+
+func TestHostOf(t *testing.T) {
+	p := &provider{Name: "test", Domain: "https://example.com/csaf"}
+	host, err := hostOf(p)
+	if err != nil {
+		t.Fatalf("hostOf: %v", err)
+	}
+	if want := "example.com"; host != want {
+		t.Fatalf("hostOf = %q, want %q", host, want)
+	}
+
+	p = &provider{Name: "bad", Domain: "not-a-url"}
+	if _, err := hostOf(p); err == nil {
+		t.Fatal("expected an error for a domain with no host")
+	}
+}
@@ -0,0 +1,187 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/youmark/pkcs8"
+)
+
+// decryptPEMKey decrypts a PEM-encoded private key and returns it
+// re-encoded as an unencrypted PEM block, ready for tls.X509KeyPair.
+// It understands PKCS#8-encrypted keys ("ENCRYPTED PRIVATE KEY", as
+// produced by "openssl pkcs8 -topk8 -v2 ...") as well as the legacy
+// OpenSSL DEK-Info PEM encryption.
+func decryptPEMKey(keyPEM []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(passphrase))
+		if err != nil {
+			return nil, fmt.Errorf("parsing PKCS#8 encrypted key: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	}
+
+	//lint:ignore SA1019 still needed for the legacy DEK-Info format
+	der, err := x509.DecryptPEMBlock(block, []byte(passphrase)) //nolint:staticcheck
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+}
+
+// clientCert resolves the certificate/key pair configured for p,
+// falling back to the top-level defaults in c, and caches the parsed
+// result so it isn't re-read for every request.
+func (c *config) clientCert(p *provider) (*tls.Certificate, error) {
+	certFile := p.ClientCert
+	if certFile == nil {
+		certFile = c.ClientCert
+	}
+	keyFile := p.ClientKey
+	if keyFile == nil {
+		keyFile = c.ClientKey
+	}
+	if certFile == nil && keyFile == nil {
+		return nil, nil
+	}
+	if certFile == nil || keyFile == nil {
+		return nil, fmt.Errorf("provider %q: client_cert and client_key must both be set", p.Name)
+	}
+
+	p.tlsCertMu.Lock()
+	defer p.tlsCertMu.Unlock()
+	if p.tlsCert != nil || p.tlsCertErr != nil {
+		return p.tlsCert, p.tlsCertErr
+	}
+
+	passphrase := p.ClientKeyPassphrase
+	if passphrase == nil {
+		passphrase = c.ClientKeyPassphrase
+	}
+
+	var cert tls.Certificate
+	if passphrase != nil {
+		resolved, err := resolveSecret(c.Vault, *passphrase)
+		if err != nil {
+			p.tlsCertErr = fmt.Errorf("resolving client_key_passphrase: %w", err)
+			return nil, p.tlsCertErr
+		}
+		cert, p.tlsCertErr = loadEncryptedX509KeyPair(*certFile, *keyFile, resolved)
+	} else {
+		cert, p.tlsCertErr = tls.LoadX509KeyPair(*certFile, *keyFile)
+	}
+	if p.tlsCertErr != nil {
+		return nil, p.tlsCertErr
+	}
+	p.tlsCert = &cert
+	return p.tlsCert, nil
+}
+
+// loadEncryptedX509KeyPair loads a certificate whose PKCS#8 private key
+// is PEM-encrypted with passphrase.
+func loadEncryptedX509KeyPair(certFile, keyFile, passphrase string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDER, err := decryptPEMKey(keyPEM, passphrase)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("decrypting %q: %w", keyFile, err)
+	}
+	return tls.X509KeyPair(certPEM, keyDER)
+}
+
+// rootCAs builds a certificate pool from the provider's pinned CA
+// files, so operators can trust an internal CA without disabling
+// verification via Insecure.
+func (p *provider) rootCAs() (*x509.CertPool, error) {
+	if len(p.RootCAs) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, path := range p.RootCAs {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading root_cas entry %q: %w", path, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", path)
+		}
+	}
+	return pool, nil
+}
+
+// tlsConfig builds the *tls.Config to use for p, combining Insecure,
+// the client certificate and the pinned root CAs. It returns nil if
+// none of these were configured.
+func (c *config) tlsConfig(p *provider) (*tls.Config, error) {
+	cert, err := c.clientCert(p)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := p.rootCAs()
+	if err != nil {
+		return nil, err
+	}
+	insecure := p.Insecure != nil && *p.Insecure || c.Insecure != nil && *c.Insecure
+	if cert == nil && pool == nil && !insecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
+	if pool != nil {
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// failingRoundTripper rejects every request with err without ever
+// dialing, used when a provider's TLS configuration could not be
+// rebuilt so we fail closed instead of silently downgrading.
+type failingRoundTripper struct {
+	err error
+}
+
+func (f *failingRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+// checkClientCert validates, at startup, that a provider's configured
+// TLS material exists and that the key actually matches the cert.
+func (c *config) checkClientCert(p *provider) error {
+	if _, err := c.clientCert(p); err != nil {
+		return fmt.Errorf("provider %q: %w", p.Name, err)
+	}
+	if _, err := p.rootCAs(); err != nil {
+		return fmt.Errorf("provider %q: %w", p.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProviderRootCAsEmpty(t *testing.T) {
+	p := &provider{Name: "test"}
+	pool, err := p.rootCAs()
+	if err != nil {
+		t.Fatalf("rootCAs with none configured: %v", err)
+	}
+	if pool != nil {
+		t.Fatal("expected a nil pool when root_cas is empty")
+	}
+}
+
+func TestProviderRootCAsMissingFile(t *testing.T) {
+	p := &provider{Name: "test", RootCAs: []string{"/does/not/exist.pem"}}
+	if _, err := p.rootCAs(); err == nil {
+		t.Fatal("expected an error for a missing root_cas file")
+	}
+}
+
+func TestProviderRootCAsInvalidPEM(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("writing ca file: %v", err)
+	}
+	p := &provider{Name: "test", RootCAs: []string{path}}
+	if _, err := p.rootCAs(); err == nil {
+		t.Fatal("expected an error for a root_cas file with no certificates")
+	}
+}
+
+func TestConfigClientCertRequiresBoth(t *testing.T) {
+	certFile := "cert.pem"
+	c := &config{}
+	p := &provider{Name: "test", ClientCert: &certFile}
+	if _, err := c.clientCert(p); err == nil {
+		t.Fatal("expected an error when client_key is missing")
+	}
+}
+
+func TestConfigClientCertNoneConfigured(t *testing.T) {
+	c := &config{}
+	p := &provider{Name: "test"}
+	cert, err := c.clientCert(p)
+	if err != nil {
+		t.Fatalf("clientCert with none configured: %v", err)
+	}
+	if cert != nil {
+		t.Fatal("expected a nil certificate when none is configured")
+	}
+}
@@ -0,0 +1,87 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/csaf-poc/csaf_distribution/internal/secrets"
+)
+
+const (
+	encSecretPrefix  = "enc:"
+	envSecretPrefix  = "env:"
+	fileSecretPrefix = "file:"
+
+	configKeyEnvVar = secrets.ConfigKeyEnvVar
+	configKeySize   = secrets.ConfigKeySize
+)
+
+// decryptSecret decrypts a base64-encoded "enc:" value produced by
+// encryptSecret (or csaf_aggregator_secret).
+func decryptSecret(key []byte, b64 string) (string, error) {
+	return secrets.Decrypt(key, b64)
+}
+
+// encryptSecret encrypts plain with key and returns the base64 blob
+// understood by decryptSecret, i.e. the value to put after "enc:".
+func encryptSecret(key []byte, plain string) (string, error) {
+	return secrets.Encrypt(key, plain)
+}
+
+// readSecretFile reads a secret from path, rejecting it if any group or
+// other permission bit is set (e.g. 0644), so operators notice if
+// permissions are too loose.
+func readSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("%q must not be readable by group or others (mode %04o)", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\n"), nil
+}
+
+// resolveSecret resolves a configuration value that may be indirected
+// via an "enc:", "env:" or "file:" prefix, or a "vault:" reference
+// resolved through vc. Plain values are returned unchanged.
+func resolveSecret(vc *vaultConfig, s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, encSecretPrefix):
+		key, err := secrets.LoadConfigKey()
+		if err != nil {
+			return "", err
+		}
+		return decryptSecret(key, strings.TrimPrefix(s, encSecretPrefix))
+	case strings.HasPrefix(s, envSecretPrefix):
+		name := strings.TrimPrefix(s, envSecretPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %q is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(s, fileSecretPrefix):
+		return readSecretFile(strings.TrimPrefix(s, fileSecretPrefix))
+	case isVaultRef(s):
+		if vc == nil {
+			return "", errors.New("vault reference used but no [vault] section configured")
+		}
+		return vc.resolve(s)
+	default:
+		return s, nil
+	}
+}
@@ -0,0 +1,100 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecryptSecretRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, configKeySize)
+
+	blob, err := encryptSecret(key, "super secret")
+	if err != nil {
+		t.Fatalf("encryptSecret: %v", err)
+	}
+
+	plain, err := decryptSecret(key, blob)
+	if err != nil {
+		t.Fatalf("decryptSecret: %v", err)
+	}
+	if plain != "super secret" {
+		t.Fatalf("got %q, want %q", plain, "super secret")
+	}
+}
+
+func TestDecryptSecretWrongKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, configKeySize)
+	other := bytes.Repeat([]byte{0x43}, configKeySize)
+
+	blob, err := encryptSecret(key, "super secret")
+	if err != nil {
+		t.Fatalf("encryptSecret: %v", err)
+	}
+	if _, err := decryptSecret(other, blob); err == nil {
+		t.Fatal("decryptSecret with wrong key should fail")
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("CSAF_TEST_SECRET", "from-env")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("writing secret file: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "plain-value", "plain-value"},
+		{"env", "env:CSAF_TEST_SECRET", "from-env"},
+		{"file", "file:" + path, "from-file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSecret(nil, tt.in)
+			if err != nil {
+				t.Fatalf("resolveSecret(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Fatalf("resolveSecret(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSecretMissingEnv(t *testing.T) {
+	if _, err := resolveSecret(nil, "env:CSAF_TEST_SECRET_DOES_NOT_EXIST"); err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+}
+
+func TestResolveSecretFileRejectsLoosePerms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	if err := os.WriteFile(path, []byte("from-file"), 0o644); err != nil {
+		t.Fatalf("writing secret file: %v", err)
+	}
+	if _, err := resolveSecret(nil, "file:"+path); err == nil {
+		t.Fatal("expected an error for a world-readable secret file")
+	}
+}
+
+func TestResolveSecretVaultWithoutConfig(t *testing.T) {
+	if _, err := resolveSecret(nil, "vault:secret/data/csaf#passphrase"); err == nil {
+		t.Fatal("expected an error for a vault reference with no [vault] section")
+	}
+}
@@ -0,0 +1,98 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// cmdServe implements the "serve" subcommand: it stands up an HTTPS
+// listener in front of cfg.Web, provisioning and renewing its
+// certificate via ACME.
+func cmdServe(cfg *config) error {
+	if cfg.ACME == nil {
+		return errors.New("serve requires an [acme] section in the configuration")
+	}
+	ac := cfg.ACME
+
+	directory, err := url.Parse(ac.DirectoryURL)
+	if err != nil {
+		return err
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(ac.CacheDir),
+		HostPolicy: autocert.HostWhitelist(ac.Domains...),
+		Email:      ac.Email,
+		Client:     &acme.Client{DirectoryURL: directory.String()},
+	}
+
+	server := &http.Server{
+		Addr:      ":https",
+		TLSConfig: manager.TLSConfig(),
+		Handler:   http.FileServer(http.Dir(cfg.Web)),
+	}
+
+	go serveHTTPChallenges(ac, manager)
+
+	return server.ListenAndServeTLS("", "")
+}
+
+// serveHTTPChallenges answers ACME HTTP-01 challenges on :80. If
+// ac.HTTPChallengeDir is set, each challenge response is also written
+// there as a plain file, so it's satisfied even when :80 is fronted by
+// a separate web server serving that directory as static files.
+func serveHTTPChallenges(ac *acmeConfig, manager *autocert.Manager) {
+	handler := manager.HTTPHandler(nil)
+	if ac.HTTPChallengeDir != "" {
+		handler = persistChallengeResponses(ac.HTTPChallengeDir, handler)
+	}
+	if err := http.ListenAndServe(":http", handler); err != nil {
+		log.Printf("acme: http-01 challenge listener failed: %v", err)
+	}
+}
+
+const acmeChallengePrefix = "/.well-known/acme-challenge/"
+
+// persistChallengeResponses wraps next so that, for ACME HTTP-01
+// requests, its response body is additionally written to dir under
+// the challenge token's name.
+func persistChallengeResponses(dir string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.URL.Path, acmeChallengePrefix)
+		if token == r.URL.Path {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		next.ServeHTTP(rec, r)
+		if rec.Code == http.StatusOK {
+			path := filepath.Join(dir, token)
+			if err := os.WriteFile(path, rec.Body.Bytes(), 0o600); err != nil {
+				log.Printf("acme: writing challenge token %q: %v", path, err)
+			}
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	})
+}
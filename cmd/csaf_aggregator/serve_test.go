@@ -0,0 +1,61 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistChallengeResponsesWritesToken(t *testing.T) {
+	dir := t.TempDir()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("challenge-response"))
+	})
+	handler := persistChallengeResponses(dir, next)
+
+	req := httptest.NewRequest(http.MethodGet, acmeChallengePrefix+"sometoken", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	data, err := os.ReadFile(filepath.Join(dir, "sometoken"))
+	if err != nil {
+		t.Fatalf("expected the challenge response to be persisted: %v", err)
+	}
+	if string(data) != "challenge-response" {
+		t.Fatalf("persisted challenge response = %q, want %q", data, "challenge-response")
+	}
+}
+
+func TestPersistChallengeResponsesIgnoresOtherPaths(t *testing.T) {
+	dir := t.TempDir()
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+	handler := persistChallengeResponses(dir, next)
+
+	req := httptest.NewRequest(http.MethodGet, "/not-a-challenge", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatal("expected the wrapped handler to be called for non-challenge requests")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing written to dir for a non-challenge request, got %v", entries)
+	}
+}
@@ -0,0 +1,361 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vaultRefPrefix = "vault:"
+	// vaultTokenRenewInterval is used when a token's actual lease
+	// duration isn't known, e.g. for a statically configured Token.
+	vaultTokenRenewInterval = 30 * time.Minute
+	// vaultRenewFraction is how much of a token's lease duration is
+	// allowed to elapse before it's renewed, leaving headroom in case
+	// the renewal request itself is delayed or needs a retry.
+	vaultRenewFraction = 0.5
+)
+
+// vaultConfig configures access to a HashiCorp Vault KV v2 store used to
+// resolve sensitive configuration values, such as the signing passphrase
+// or the OpenPGP private key, instead of keeping them in the TOML file.
+type vaultConfig struct {
+	Address  string `toml:"address"`
+	Token    string `toml:"token"`
+	RoleID   string `toml:"role_id"`
+	SecretID string `toml:"secret_id"`
+	Mount    string `toml:"mount"`
+	Path     string `toml:"path"`
+	CACert   string `toml:"ca_cert"`
+
+	mu            sync.Mutex
+	client        *http.Client
+	token         string
+	leaseDuration time.Duration
+	viaAppRole    bool
+	renewStop     chan struct{}
+}
+
+// isVaultRef reports whether s references a secret stored in Vault,
+// e.g. "vault:secret/data/csaf#passphrase".
+func isVaultRef(s string) bool {
+	return strings.HasPrefix(s, vaultRefPrefix)
+}
+
+func (vc *vaultConfig) httpClient() (*http.Client, error) {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	if vc.client != nil {
+		return vc.client, nil
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	if vc.CACert != "" {
+		pem, err := os.ReadFile(vc.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading vault ca_cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %q", vc.CACert)
+		}
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	vc.client = client
+	return client, nil
+}
+
+// ensureToken makes sure vc has a usable token, logging in via AppRole
+// if no static token was configured, and starts the background renewal
+// loop on first use.
+func (vc *vaultConfig) ensureToken() error {
+	vc.mu.Lock()
+	haveToken := vc.token != ""
+	vc.mu.Unlock()
+	if haveToken {
+		return nil
+	}
+
+	var (
+		token      string
+		lease      time.Duration
+		viaAppRole bool
+	)
+	switch {
+	case vc.Token != "":
+		var err error
+		// The vault token itself can't be a "vault:" reference, that
+		// would be circular; "enc:"/"env:"/"file:" are fine.
+		if token, err = resolveSecret(nil, vc.Token); err != nil {
+			return fmt.Errorf("resolving vault token: %w", err)
+		}
+	case vc.RoleID != "" && vc.SecretID != "":
+		var err error
+		if token, lease, err = vc.login(); err != nil {
+			return fmt.Errorf("vault approle login failed: %w", err)
+		}
+		viaAppRole = true
+	default:
+		return errors.New("vault configured without a token or approle credentials")
+	}
+
+	vc.mu.Lock()
+	vc.token = token
+	vc.leaseDuration = lease
+	vc.viaAppRole = viaAppRole
+	vc.renewStop = make(chan struct{})
+	vc.mu.Unlock()
+
+	go vc.renewLoop()
+	return nil
+}
+
+// login performs an AppRole login and returns the resulting client
+// token and its lease duration, so the caller knows how soon it needs
+// renewing instead of guessing.
+func (vc *vaultConfig) login() (string, time.Duration, error) {
+	client, err := vc.httpClient()
+	if err != nil {
+		return "", 0, err
+	}
+	// vc.SecretID can't be a "vault:" reference, that would be
+	// circular; "enc:"/"env:"/"file:" are fine.
+	secretID, err := resolveSecret(nil, vc.SecretID)
+	if err != nil {
+		return "", 0, fmt.Errorf("resolving vault secret_id: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"role_id":   vc.RoleID,
+		"secret_id": secretID,
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := client.Post(
+		strings.TrimSuffix(vc.Address, "/")+"/v1/auth/approle/login",
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("vault approle login returned status %s", resp.Status)
+	}
+	var auth struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&auth); err != nil {
+		return "", 0, err
+	}
+	lease := time.Duration(auth.Auth.LeaseDuration) * time.Second
+	return auth.Auth.ClientToken, lease, nil
+}
+
+// renewInterval returns how long renewLoop should wait before its next
+// renewal attempt: a fraction of the token's actual lease duration if
+// known, or vaultTokenRenewInterval otherwise (e.g. for a statically
+// configured Token, which has no lease to read).
+func (vc *vaultConfig) renewInterval() time.Duration {
+	vc.mu.Lock()
+	lease := vc.leaseDuration
+	vc.mu.Unlock()
+	if lease <= 0 {
+		return vaultTokenRenewInterval
+	}
+	return time.Duration(float64(lease) * vaultRenewFraction)
+}
+
+func (vc *vaultConfig) renewLoop() {
+	for {
+		timer := time.NewTimer(vc.renewInterval())
+		select {
+		case <-timer.C:
+			if err := vc.renew(); err != nil {
+				log.Printf("vault token renewal failed: %v", err)
+			}
+		case <-vc.renewStop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// renew extends the current token's lease via renew-self. If the
+// token has already expired (a 403 from Vault), and it was obtained
+// via AppRole, it falls back to logging in again instead of renewing
+// a dead token forever.
+func (vc *vaultConfig) renew() error {
+	err := vc.renewSelf()
+	if err == nil {
+		return nil
+	}
+	vc.mu.Lock()
+	viaAppRole := vc.viaAppRole
+	vc.mu.Unlock()
+	if !errors.Is(err, errVaultRenewForbidden) || !viaAppRole {
+		return err
+	}
+	log.Printf("vault token renewal forbidden, logging in again: %v", err)
+	token, lease, err := vc.login()
+	if err != nil {
+		return fmt.Errorf("re-login after failed renewal: %w", err)
+	}
+	vc.mu.Lock()
+	vc.token = token
+	vc.leaseDuration = lease
+	vc.mu.Unlock()
+	return nil
+}
+
+// errVaultRenewForbidden marks a renew-self failure caused by Vault
+// rejecting the token outright (HTTP 403), as opposed to a transient
+// network or server error.
+var errVaultRenewForbidden = errors.New("vault rejected the token renewal")
+
+func (vc *vaultConfig) renewSelf() error {
+	client, err := vc.httpClient()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(
+		http.MethodPost,
+		strings.TrimSuffix(vc.Address, "/")+"/v1/auth/token/renew-self", nil)
+	if err != nil {
+		return err
+	}
+	vc.mu.Lock()
+	req.Header.Set("X-Vault-Token", vc.token)
+	vc.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: status %s", errVaultRenewForbidden, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault token renewal returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// parseVaultRef splits a "vault:<path>#<field>" reference into the
+// KV v2 path and the field name to read from its JSON document.
+func parseVaultRef(ref string) (path, field string, err error) {
+	ref = strings.TrimPrefix(ref, vaultRefPrefix)
+	idx := strings.LastIndex(ref, "#")
+	if idx < 0 {
+		return "", "", fmt.Errorf("invalid vault reference %q: missing '#field'", ref)
+	}
+	return ref[:idx], ref[idx+1:], nil
+}
+
+func (vc *vaultConfig) fetch(path string) (map[string]interface{}, error) {
+	if err := vc.ensureToken(); err != nil {
+		return nil, err
+	}
+	client, err := vc.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(
+		http.MethodGet, strings.TrimSuffix(vc.Address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	vc.mu.Lock()
+	req.Header.Set("X-Vault-Token", vc.token)
+	vc.mu.Unlock()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned status %s for %q", resp.Status, path)
+	}
+	var doc struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decoding vault response: %w", err)
+	}
+	return doc.Data.Data, nil
+}
+
+// kvPath resolves the KV v2 path to read. An explicit path in the
+// reference (e.g. "secret/data/csaf") is used as-is; a reference with
+// no path (e.g. "vault:#passphrase") falls back to the [vault]
+// section's Mount and Path.
+func (vc *vaultConfig) kvPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	if vc.Mount == "" || vc.Path == "" {
+		return "", errors.New("vault reference has no path, and [vault] mount/path are not both set")
+	}
+	return strings.TrimSuffix(vc.Mount, "/") + "/data/" + strings.TrimPrefix(vc.Path, "/"), nil
+}
+
+// resolve fetches the secret referenced by ref, a "vault:<path>#<field>"
+// string, and returns the named field as a string. If the reference
+// omits the path, it defaults to the configured Mount and Path.
+func (vc *vaultConfig) resolve(ref string) (string, error) {
+	rawPath, field, err := parseVaultRef(ref)
+	if err != nil {
+		return "", err
+	}
+	path, err := vc.kvPath(rawPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := vc.fetch(path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %q has no field %q", path, field)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %q field %q is not a string", path, field)
+	}
+	return s, nil
+}
+
+// open resolves ref and returns it as a reader, so armored keys fetched
+// from Vault never have to be written to disk.
+func (vc *vaultConfig) open(ref string) (io.ReadCloser, error) {
+	s, err := vc.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(s)), nil
+}
@@ -0,0 +1,77 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package main
+
+import "testing"
+
+func TestParseVaultRef(t *testing.T) {
+	tests := []struct {
+		name      string
+		ref       string
+		wantPath  string
+		wantField string
+		wantErr   bool
+	}{
+		{"full path", "vault:secret/data/csaf#passphrase", "secret/data/csaf", "passphrase", false},
+		{"hash in path, last hash starts the field", "vault:secret/data/csaf#a#b", "secret/data/csaf#a", "b", false},
+		{"empty path, field only", "vault:#passphrase", "", "passphrase", false},
+		{"missing field", "vault:secret/data/csaf", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, field, err := parseVaultRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseVaultRef(%q): expected an error", tt.ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVaultRef(%q): %v", tt.ref, err)
+			}
+			if path != tt.wantPath || field != tt.wantField {
+				t.Fatalf("parseVaultRef(%q) = (%q, %q), want (%q, %q)",
+					tt.ref, path, field, tt.wantPath, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestVaultConfigKVPath(t *testing.T) {
+	vc := &vaultConfig{Mount: "secret", Path: "csaf"}
+
+	got, err := vc.kvPath("")
+	if err != nil {
+		t.Fatalf("kvPath(\"\"): %v", err)
+	}
+	if want := "secret/data/csaf"; got != want {
+		t.Fatalf("kvPath(\"\") = %q, want %q", got, want)
+	}
+
+	got, err = vc.kvPath("other/data/path")
+	if err != nil {
+		t.Fatalf("kvPath with explicit path: %v", err)
+	}
+	if want := "other/data/path"; got != want {
+		t.Fatalf("kvPath with explicit path = %q, want %q", got, want)
+	}
+
+	if _, err := (&vaultConfig{}).kvPath(""); err == nil {
+		t.Fatal("kvPath with no path and no mount/path configured should fail")
+	}
+}
+
+func TestIsVaultRef(t *testing.T) {
+	if !isVaultRef("vault:secret/data/csaf#passphrase") {
+		t.Fatal("expected a vault: prefix to be recognized")
+	}
+	if isVaultRef("enc:abcd") {
+		t.Fatal("enc: prefix should not be recognized as a vault reference")
+	}
+}
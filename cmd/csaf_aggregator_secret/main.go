@@ -0,0 +1,53 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+// Command csaf_aggregator_secret encrypts a plaintext value with the
+// key named in $CSAF_AGGREGATOR_CONFIG_KEY, producing an "enc:" blob
+// that can be pasted into aggregator.toml. The plaintext is read from
+// stdin, not argv, so it never ends up in shell history or "ps" output.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/csaf-poc/csaf_distribution/internal/secrets"
+)
+
+func run() error {
+	if len(os.Args) != 1 {
+		return fmt.Errorf("usage: %s < plaintext-value", os.Args[0])
+	}
+	plain, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading plaintext from stdin: %w", err)
+	}
+	plain = strings.TrimRight(plain, "\n")
+
+	key, err := secrets.LoadConfigKey()
+	if err != nil {
+		return err
+	}
+	blob, err := secrets.Encrypt(key, plain)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("enc:%s\n", blob)
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatalln("error:", err)
+	}
+}
@@ -0,0 +1,128 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+// Package secrets implements the AES-256-GCM encryption used for
+// "enc:" indirected values in aggregator.toml, shared between
+// csaf_aggregator and the csaf_aggregator_secret helper.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	// ConfigKeyEnvVar names the environment variable holding the path
+	// to the AES-256-GCM key file.
+	ConfigKeyEnvVar = "CSAF_AGGREGATOR_CONFIG_KEY"
+	// ConfigKeySize is the required length, in bytes, of that key.
+	ConfigKeySize = 32
+
+	// keyringService and keyringUser identify the config key entry in
+	// the OS keyring, used as a fallback when ConfigKeyEnvVar isn't set.
+	keyringService = "csaf_aggregator"
+	keyringUser    = "config-key"
+)
+
+// LoadConfigKey loads the key file named in $CSAF_AGGREGATOR_CONFIG_KEY,
+// falling back to a base64-encoded entry in the OS keyring if that
+// variable isn't set.
+func LoadConfigKey() ([]byte, error) {
+	path := os.Getenv(ConfigKeyEnvVar)
+	if path == "" {
+		return loadConfigKeyFromKeyring()
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config key: %w", err)
+	}
+	return checkConfigKeySize(key)
+}
+
+func loadConfigKeyFromKeyring() ([]byte, error) {
+	b64, err := keyring.Get(keyringService, keyringUser)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"%s is not set, and no config key was found in the OS keyring: %w",
+			ConfigKeyEnvVar, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, fmt.Errorf("decoding config key from keyring: %w", err)
+	}
+	return checkConfigKeySize(key)
+}
+
+func checkConfigKeySize(key []byte) ([]byte, error) {
+	if len(key) != ConfigKeySize {
+		return nil, fmt.Errorf("config key must be %d bytes, got %d", ConfigKeySize, len(key))
+	}
+	return key, nil
+}
+
+// StoreConfigKeyInKeyring saves key in the OS keyring, base64-encoded,
+// so LoadConfigKey can find it without $CSAF_AGGREGATOR_CONFIG_KEY set.
+func StoreConfigKeyInKeyring(key []byte) error {
+	if _, err := checkConfigKeySize(key); err != nil {
+		return err
+	}
+	return keyring.Set(keyringService, keyringUser, base64.StdEncoding.EncodeToString(key))
+}
+
+func gcmFromKey(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Decrypt decrypts a base64-encoded "nonce || ciphertext" blob
+// produced by Encrypt.
+func Decrypt(key []byte, b64 string) (string, error) {
+	gcm, err := gcmFromKey(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return "", fmt.Errorf("decoding enc value: %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("enc value is too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting enc value: %w", err)
+	}
+	return string(plain), nil
+}
+
+// Encrypt encrypts plain with key and returns the base64 blob
+// understood by Decrypt, i.e. the value to put after "enc:".
+func Encrypt(key []byte, plain string) (string, error) {
+	gcm, err := gcmFromKey(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	ciphertext := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
@@ -0,0 +1,30 @@
+// This file is Free Software under the MIT License
+// without warranty, see README.md and LICENSES/MIT.txt for details.
+//
+// SPDX-License-Identifier: MIT
+//
+// SPDX-FileCopyrightText: 2022 German Federal Office for Information Security (BSI) <https://www.bsi.bund.de>
+// Software-Engineering: 2022 Intevation GmbH <https://intevation.de>
+
+package secrets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x7a}, ConfigKeySize)
+
+	blob, err := Encrypt(key, "hunter2")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	plain, err := Decrypt(key, blob)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if plain != "hunter2" {
+		t.Fatalf("got %q, want %q", plain, "hunter2")
+	}
+}